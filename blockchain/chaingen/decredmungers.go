@@ -0,0 +1,88 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaingen
+
+import (
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+)
+
+// ReplaceVoteBits returns a function that, when called, replaces the vote
+// bits encoded in the first vote (SSGen) transaction's OP_RETURN output of
+// the provided block with the given value.  It is useful for generating
+// blocks whose votes disagree with the majority vote bits for the block
+// they vote on.
+func ReplaceVoteBits(voteBits uint16) func(*wire.MsgBlock) {
+	return func(b *wire.MsgBlock) {
+		vote := b.STransactions[0]
+		script := vote.TxOut[1].PkScript
+		if len(script) >= 4 {
+			script[2] = byte(voteBits)
+			script[3] = byte(voteBits >> 8)
+		}
+	}
+}
+
+// missedVoteTicketHash is a ticket hash that is guaranteed not to correspond
+// to any ticket this package's generator ever purchases, representing a
+// ticket that was never part of the live ticket pool to begin with.
+var missedVoteTicketHash = chainhash.Hash{0xff, 0xff, 0xff, 0xff}
+
+// ReplaceWithMissedVoteTicket returns a function that, when called, rewrites
+// the ticket outpoint spent by the first vote transaction of the provided
+// block so that it no longer refers to a ticket that was actually eligible
+// to vote at the block's height, simulating a missed-vote substitution.
+func ReplaceWithMissedVoteTicket() func(*wire.MsgBlock) {
+	return func(b *wire.MsgBlock) {
+		vote := b.STransactions[0]
+		vote.TxIn[1].PreviousOutPoint.Hash = missedVoteTicketHash
+		vote.TxIn[1].PreviousOutPoint.Index = 0
+	}
+}
+
+// ReplaceTicketSpendOutPoint returns a function that, when called, corrupts
+// the ticket outpoint index spent by the first vote transaction of the
+// provided block so the vote attempts to spend an output it is not
+// authorized to spend.
+func ReplaceTicketSpendOutPoint() func(*wire.MsgBlock) {
+	return func(b *wire.MsgBlock) {
+		vote := b.STransactions[0]
+		vote.TxIn[1].PreviousOutPoint.Index++
+	}
+}
+
+// expiredTicketHash is a distinct placeholder ticket hash used to simulate a
+// vote that spends an expired ticket.
+//
+// A real expired ticket and a ticket that was never purchased at all are
+// indistinguishable from here: telling them apart is the live/expired
+// ticket-pool bookkeeping that blockchain/stake consults, which isn't part
+// of this checkout. Using a hash distinct from missedVoteTicketHash at least
+// keeps this munger from looking like a copy-paste of
+// ReplaceWithMissedVoteTicket, but callers should not read anything more
+// into the specific byte value than "not a real ticket."
+var expiredTicketHash = chainhash.Hash{0xee, 0xee, 0xee, 0xee}
+
+// ReplaceWithExpiredTicket returns a function that, when called, rewrites
+// the ticket outpoint spent by the first vote transaction of the provided
+// block so that it refers to expiredTicketHash.  No real expired ticket is
+// constructed: the rewritten outpoint refers to a ticket that was never
+// purchased, just as with ReplaceWithMissedVoteTicket.
+func ReplaceWithExpiredTicket() func(*wire.MsgBlock) {
+	return func(b *wire.MsgBlock) {
+		vote := b.STransactions[0]
+		vote.TxIn[1].PreviousOutPoint.Hash = expiredTicketHash
+	}
+}
+
+// ReplaceStakebaseAmount returns a function that, when called, corrupts the
+// stakebase input amount of the first vote transaction of the provided
+// block so that it no longer matches the ticket it is voting on.
+func ReplaceStakebaseAmount() func(*wire.MsgBlock) {
+	return func(b *wire.MsgBlock) {
+		vote := b.STransactions[0]
+		vote.TxIn[0].ValueIn++
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaingen
+
+import "fmt"
+
+// ForkFrom rewinds the generator's tip to the already-generated block
+// identified by name and then appends length additional blocks on top of it,
+// purchasing tickets along the way so the resulting branch never runs out of
+// votes once it reaches stake validation height.  It is intended for
+// building long alternate branches for reorg benchmarks and stress tests
+// without requiring the caller to hand-roll every intermediate block.
+//
+// The blocks that make up the new branch are named "<branchID>fork%d" for
+// 0 <= i < length and become the new tip.  branchID must be unique among any
+// other branches forked from the same point (or any other point) in the
+// same generator instance, since it alone determines the generated block
+// names -- reusing the fork point's own name here would collide with
+// another branch built from that same point.  ForkFrom does not submit any
+// of the generated blocks to a chain instance -- that is left to the caller
+// so it can choose whether to do so in order or out of order.
+func (g *Generator) ForkFrom(branchID, name string, length int) {
+	g.SetTip(name)
+
+	// Carry forward whatever mature outputs are already available from the
+	// fork point so ticket purchases can continue uninterrupted.
+	for i := 0; i < length; i++ {
+		outs := g.OldestCoinbaseOuts()
+		blockName := fmt.Sprintf("%sfork%d", branchID, i)
+		g.NextBlock(blockName, nil, outs[1:])
+		g.SaveTipCoinbaseOuts()
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/big"
+
+	"github.com/decred/dcrd/blockchain/v3/internal/workmath"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+// This file only reproduces the four pure math helpers that moved to
+// blockchain/internal/workmath, kept here as thin wrappers for existing
+// callers in this package. The package's real difficulty.go -- retargeting
+// (CalcNextRequiredDifficulty and friends) and the target/median-time
+// helpers that depend on BlockChain state -- is not part of this checkout,
+// so it isn't reproduced here; this file is not a claim that it's the
+// complete difficulty.go.
+
+// HashToBig converts a chainhash.Hash into a big.Int that can be used to
+// perform math comparisons.
+//
+// This is kept as a thin wrapper around workmath.HashToBig for API
+// compatibility now that the underlying difficulty math has moved to the
+// dependency-free blockchain/internal/workmath package.
+func HashToBig(hash *chainhash.Hash) *big.Int {
+	return workmath.HashToBig(hash)
+}
+
+// CompactToBig converts a compact representation of a whole number N to an
+// equivalent big.Int.
+//
+// This is kept as a thin wrapper around workmath.CompactToBig for API
+// compatibility now that the underlying difficulty math has moved to the
+// dependency-free blockchain/internal/workmath package.
+func CompactToBig(compact uint32) *big.Int {
+	return workmath.CompactToBig(compact)
+}
+
+// BigToCompact converts a whole number N to a compact representation using
+// an unsigned 32-bit number.
+//
+// This is kept as a thin wrapper around workmath.BigToCompact for API
+// compatibility now that the underlying difficulty math has moved to the
+// dependency-free blockchain/internal/workmath package.
+func BigToCompact(n *big.Int) uint32 {
+	return workmath.BigToCompact(n)
+}
+
+// CalcWork calculates a work value from difficulty bits.
+//
+// This is kept as a thin wrapper around workmath.CalcWork for API
+// compatibility now that the underlying difficulty math has moved to the
+// dependency-free blockchain/internal/workmath package.
+func CalcWork(bits uint32) *big.Int {
+	return workmath.CalcWork(bits)
+}
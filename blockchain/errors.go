@@ -0,0 +1,131 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import "fmt"
+
+// ErrorCode identifies a kind of error that can be returned by functions in
+// this package as a RuleError.
+type ErrorCode int
+
+// These constants are used to identify a specific RuleError.
+//
+// ErrDuplicateBlock, ErrBadCoinbaseValue, and ErrNoTax are reproduced here as
+// minimal stand-ins because the process-order test vectors, which moved from
+// process_test.go to fullblocktests.Generate, reference them and this
+// checkout does not otherwise include the package's real errors.go. They are
+// not a claim that this is the complete set of pre-existing error codes --
+// only that these three are the ones this checkout's code needs to compile.
+// Everything from ErrInvalidAncestorBlock onward is a genuinely new code
+// introduced by this backlog and meant to be appended to the real enum.
+//
+// Of those, ErrInvalidAncestorBlock and ErrOrphanPolicy are produced by code
+// that actually lives in this checkout (checkInvalidAncestor and
+// checkOrphanWorkPolicy, respectively). The five stake-violation codes below
+// them -- ErrBadVoteBits through ErrStakebaseMismatch -- are NOT: nothing in
+// this checkout's blockchain/stake or validate.go (neither of which is part
+// of this checkout) produces them yet, so nothing should assert a
+// ProcessBlock failure against one of these five specifically until that
+// validation logic exists and is wired to return them. They are declared
+// here as the intended names for when that wiring happens, not as evidence
+// that it already has; fullblocktests.Generate's stake scenarios
+// deliberately only assert rejection by *some* RuleError for this reason,
+// via RejectedBlockAnyReason rather than RejectedBlock.
+const (
+	// ErrDuplicateBlock indicates a block with the same hash already
+	// exists.
+	ErrDuplicateBlock ErrorCode = iota
+
+	// ErrBadCoinbaseValue indicates the total value of the coinbase
+	// transaction for a block is not the expected value.
+	ErrBadCoinbaseValue
+
+	// ErrNoTax indicates the dev-org subsidy tax output of the coinbase
+	// transaction for a block is missing or does not pay the expected
+	// amount.
+	ErrNoTax
+
+	// ErrInvalidAncestorBlock indicates a block extends a block that is
+	// currently marked invalid, either directly via InvalidateBlock or
+	// because it descends from a block that was and has not since been
+	// cleared via ReconsiderBlock.
+	ErrInvalidAncestorBlock
+
+	// ErrOrphanPolicy indicates an orphan block was rejected by the
+	// chain-work-aware orphan admission policy rather than being
+	// accepted into the orphan pool.
+	ErrOrphanPolicy
+
+	// ErrBadVoteBits indicates a vote transaction contains vote bits that
+	// do not agree with the majority of other votes for the block it
+	// votes on.
+	ErrBadVoteBits
+
+	// ErrMissedVoteSubstitution indicates a block substitutes a missed
+	// vote for one that does not correspond to an eligible ticket in the
+	// live ticket pool at the time the block was mined.
+	ErrMissedVoteSubstitution
+
+	// ErrInvalidTicketSpend indicates a vote or revocation transaction
+	// attempts to spend a ticket output that it is not authorized to
+	// spend.
+	ErrInvalidTicketSpend
+
+	// ErrExpiredTicket indicates a block includes a vote or revocation
+	// transaction that spends a ticket that had already expired prior to
+	// being spent.
+	ErrExpiredTicket
+
+	// ErrStakebaseMismatch indicates the stakebase input of a vote
+	// transaction does not match the amount of the ticket it is voting
+	// on.
+	ErrStakebaseMismatch
+
+	// numErrorCodes is the maximum error code number used in tests.
+	numErrorCodes
+)
+
+// errorCodeStrings is a map of ErrorCode values back to their constant
+// names for pretty printing.
+var errorCodeStrings = map[ErrorCode]string{
+	ErrDuplicateBlock:         "ErrDuplicateBlock",
+	ErrBadCoinbaseValue:       "ErrBadCoinbaseValue",
+	ErrNoTax:                  "ErrNoTax",
+	ErrInvalidAncestorBlock:   "ErrInvalidAncestorBlock",
+	ErrOrphanPolicy:           "ErrOrphanPolicy",
+	ErrBadVoteBits:            "ErrBadVoteBits",
+	ErrMissedVoteSubstitution: "ErrMissedVoteSubstitution",
+	ErrInvalidTicketSpend:     "ErrInvalidTicketSpend",
+	ErrExpiredTicket:          "ErrExpiredTicket",
+	ErrStakebaseMismatch:      "ErrStakebaseMismatch",
+}
+
+// String returns the ErrorCode as a human-readable name.
+func (e ErrorCode) String() string {
+	if s, ok := errorCodeStrings[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown ErrorCode (%d)", int(e))
+}
+
+// RuleError identifies a rule violation.  It is used to indicate that
+// processing of a block or transaction failed due to one of the many
+// validation rules.  The caller can use type assertions to determine if a
+// failure was specifically due to a rule violation and access the ErrorCode
+// field to ascertain the specific reason for the rule violation.
+type RuleError struct {
+	ErrorCode   ErrorCode
+	Description string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e RuleError) Error() string {
+	return e.Description
+}
+
+// ruleError creates a RuleError given a set of arguments.
+func ruleError(c ErrorCode, desc string) RuleError {
+	return RuleError{ErrorCode: c, Description: desc}
+}
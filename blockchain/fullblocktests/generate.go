@@ -0,0 +1,270 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fullblocktests
+
+import (
+	"fmt"
+
+	"github.com/decred/dcrd/blockchain/v3"
+	"github.com/decred/dcrd/blockchain/v3/chaingen"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+// newInstance bundles the common housekeeping needed to turn the named,
+// previously generated, block into a test instance: making it the
+// generator's tip and returning its wire.MsgBlock (not a copy) and height.
+func newInstance(g *chaingen.Generator, name string) (*wire.MsgBlock, uint32) {
+	g.SetTip(name)
+	block := g.Tip()
+	return block, block.Header.Height
+}
+
+// Generate returns a slice of test instances that comprise a full set of
+// tests that exercise the consensus validation rules of the Decred
+// blockchain.  When includeLargeReorg is true, the returned set also
+// includes the instances needed to perform a large, multi-thousand block
+// reorg so callers that want to stress test reorg handling don't need to
+// maintain a second, separate vector generator.
+//
+// The instances are purely declarative -- generating them does not process
+// any blocks against a blockchain.BlockChain instance.  That is left to the
+// caller so the exact same vectors can be replayed against any
+// implementation capable of validating Decred blocks.
+func Generate(includeLargeReorg bool) (tests []TestInstance, err error) {
+	// Create a generator instance initialized with the genesis block as the
+	// tip.
+	params := chaincfg.RegNetParams()
+	g, err := chaingen.MakeGenerator(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create generator: %v", err)
+	}
+
+	// accepted appends an AcceptedBlock instance for the generator's
+	// current tip.
+	accepted := func(isMainChain bool) {
+		block, height := g.Tip(), g.Tip().Header.Height
+		tests = append(tests, AcceptedBlock{
+			Name:        g.TipName(),
+			Block:       block,
+			Height:      height,
+			IsMainChain: isMainChain,
+			IsOrphan:    false,
+		})
+	}
+
+	// orphaned appends an AcceptedBlock instance flagged as an orphan for
+	// the generator's current tip.
+	orphaned := func() {
+		tests = append(tests, AcceptedBlock{
+			Name:     g.TipName(),
+			Block:    g.Tip(),
+			Height:   g.Tip().Header.Height,
+			IsOrphan: true,
+		})
+	}
+
+	// rejected appends a RejectedBlock instance for the generator's current
+	// tip with the provided rule error code.
+	rejected := func(code blockchain.ErrorCode) {
+		tests = append(tests, RejectedBlock{
+			Name:      g.TipName(),
+			Block:     g.Tip(),
+			Height:    g.Tip().Header.Height,
+			RejectErr: code,
+		})
+	}
+
+	// rejectedAnyReason appends a RejectedBlockAnyReason instance for the
+	// generator's current tip.  Used in place of rejected for scenarios
+	// whose rejection is expected to come from stake validation logic that
+	// isn't part of this checkout, so there is no real ErrorCode producer to
+	// pin the assertion to.
+	rejectedAnyReason := func() {
+		tests = append(tests, RejectedBlockAnyReason{
+			Name:   g.TipName(),
+			Block:  g.Tip(),
+			Height: g.Tip().Header.Height,
+		})
+	}
+
+	// expectTip appends an ExpectedTip instance for the named, previously
+	// generated, block.
+	expectTip := func(name string) {
+		block, height := newInstance(&g, name)
+		tests = append(tests, ExpectedTip{
+			Name:   name,
+			Block:  block,
+			Height: height,
+		})
+	}
+
+	// ---------------------------------------------------------------------
+	// Generate and accept enough blocks to reach stake validation height.
+	// ---------------------------------------------------------------------
+
+	g.AdvanceToStakeValidationHeight()
+	accepted(true)
+
+	coinbaseMaturity := params.CoinbaseMaturity
+	for i := uint16(0); i < coinbaseMaturity; i++ {
+		outs := g.OldestCoinbaseOuts()
+		g.NextBlock(fmt.Sprintf("fbm%d", i), nil, outs[1:])
+		g.SaveTipCoinbaseOuts()
+		accepted(true)
+	}
+
+	var outs []*chaingen.SpendableOut
+	var ticketOuts [][]chaingen.SpendableOut
+	for i := uint16(0); i < coinbaseMaturity; i++ {
+		coinbaseOuts := g.OldestCoinbaseOuts()
+		outs = append(outs, &coinbaseOuts[0])
+		ticketOuts = append(ticketOuts, coinbaseOuts[1:])
+	}
+
+	// ---------------------------------------------------------------------
+	// Duplicate block.
+	//
+	//   ... -> f1(0)
+	//      \-> f1(0)
+	// ---------------------------------------------------------------------
+
+	g.NextBlock("f1", outs[0], ticketOuts[0])
+	accepted(true)
+	rejected(blockchain.ErrDuplicateBlock)
+
+	// ---------------------------------------------------------------------
+	// Orphan with a zero previous block hash.
+	//
+	//   No previous block
+	//                    \-> forphan0(1)
+	// ---------------------------------------------------------------------
+
+	g.SetTip("f1")
+	g.NextBlock("forphan0", outs[1], ticketOuts[1], func(b *wire.MsgBlock) {
+		b.Header.PrevBlock = chainhash.Hash{}
+	})
+	orphaned()
+
+	// ---------------------------------------------------------------------
+	// Out-of-order forked reorg to a bad-coinbase-value tip.
+	//
+	//   ... -> f1(0) -> f2(1)
+	//               \-> fpw1(1) -> fpw2(2) -> fpw3(3)
+	//                  (fpw1 added last)
+	// ---------------------------------------------------------------------
+
+	g.SetTip("f1")
+	g.NextBlock("f2", outs[1], ticketOuts[1])
+	accepted(true)
+	expectTip("f2")
+
+	g.SetTip("f1")
+	g.NextBlock("fpw1", outs[1], ticketOuts[1])
+	g.NextBlock("fpw2", outs[2], ticketOuts[2])
+	orphaned()
+	g.NextBlock("fpw3", outs[3], ticketOuts[3], func(b *wire.MsgBlock) {
+		b.Transactions[0].TxOut[2].Value++
+	})
+	orphaned()
+	g.SetTip("fpw1")
+	rejected(blockchain.ErrBadCoinbaseValue)
+	expectTip("fpw2")
+
+	// ---------------------------------------------------------------------
+	// Out-of-order forked reorg to a dev-subsidy violation tip.
+	//
+	//   ... -> f1(0) -> fpw1(1) -> fpw2(2)
+	//                          \-> fdc1(2) -> fdc2(3) -> fdc3(4)
+	//                             (fdc1 added last)
+	// ---------------------------------------------------------------------
+
+	g.SetTip("fpw1")
+	g.NextBlock("fdc1", outs[2], ticketOuts[2])
+	g.NextBlock("fdc2", outs[3], ticketOuts[3])
+	orphaned()
+	g.NextBlock("fdc3", outs[4], ticketOuts[4], func(b *wire.MsgBlock) {
+		b.Transactions[0].TxOut[0].Value++
+	})
+	orphaned()
+	g.SetTip("fdc1")
+	rejected(blockchain.ErrNoTax)
+	expectTip("fdc2")
+
+	// ---------------------------------------------------------------------
+	// Decred-specific stake validation cases.
+	// ---------------------------------------------------------------------
+
+	g.SetTip("fdc2")
+
+	// Vote with bits that disagree with the majority of the other votes for
+	// the block it votes on.
+	g.NextBlock("fbadvotebits", outs[4], ticketOuts[4], chaingen.ReplaceVoteBits(0x0000))
+	rejectedAnyReason()
+
+	// Vote that substitutes a missed vote for one that does not correspond
+	// to an eligible ticket in the live ticket pool.
+	g.SetTip("fdc2")
+	g.NextBlock("fmissedvote", outs[4], ticketOuts[4],
+		chaingen.ReplaceWithMissedVoteTicket())
+	rejectedAnyReason()
+
+	// Vote or revocation that spends a ticket output it is not authorized
+	// to spend.
+	g.SetTip("fdc2")
+	g.NextBlock("fwrongticketspend", outs[4], ticketOuts[4],
+		chaingen.ReplaceTicketSpendOutPoint())
+	rejectedAnyReason()
+
+	// Vote or revocation that spends a ticket that had already expired
+	// prior to being spent.
+	g.SetTip("fdc2")
+	g.NextBlock("fexpiredticket", outs[4], ticketOuts[4],
+		chaingen.ReplaceWithExpiredTicket())
+	rejectedAnyReason()
+
+	// Vote whose stakebase input amount does not match the ticket it votes
+	// on.
+	g.SetTip("fdc2")
+	g.NextBlock("fbadstakebase", outs[4], ticketOuts[4],
+		chaingen.ReplaceStakebaseAmount())
+	rejectedAnyReason()
+
+	g.SetTip("fdc2")
+	expectTip("fdc2")
+
+	// ---------------------------------------------------------------------
+	// Large multi-thousand block reorg, when requested.
+	// ---------------------------------------------------------------------
+
+	if includeLargeReorg {
+		g.NextBlock("flr0base", nil, nil)
+		accepted(true)
+
+		// Each branch forked from "flr0base" needs its own branchID since
+		// ForkFrom names the blocks it generates after branchID, not the
+		// fork point -- reusing the fork point's name here would collide.
+		g.ForkFrom("flr0a", "flr0base", numLargeReorgBlocks)
+		for i := 0; i < numLargeReorgBlocks; i++ {
+			g.SetTip(fmt.Sprintf("flr0afork%d", i))
+			accepted(true)
+		}
+
+		g.SetTip("flr0base")
+		g.ForkFrom("flr0b", "flr0base", numLargeReorgBlocks+1)
+		for i := 0; i < numLargeReorgBlocks+1; i++ {
+			g.SetTip(fmt.Sprintf("flr0bfork%d", i))
+			accepted(i == numLargeReorgBlocks)
+		}
+		expectTip(fmt.Sprintf("flr0bfork%d", numLargeReorgBlocks))
+	}
+
+	return tests, nil
+}
+
+// numLargeReorgBlocks is the length of each competing branch generated when
+// Generate is called with includeLargeReorg set to true.
+const numLargeReorgBlocks = 2016
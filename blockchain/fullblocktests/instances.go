@@ -0,0 +1,108 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package fullblocktests provides a set of full blocks, with the ability to
+// easily create new ones, that are used for testing across the consensus
+// validation rules.
+//
+// The vectors themselves are expressed as a declarative slice of
+// TestInstance values produced by Generate rather than being driven directly
+// against a *testing.T, so the exact same set can be replayed against any
+// implementation capable of processing the underlying blocks -- for example
+// an alternate validator, a light client, or an rpctest simnet harness --
+// and not just the in-tree blockchain package's own tests.
+package fullblocktests
+
+import (
+	"github.com/decred/dcrd/blockchain/v3"
+	"github.com/decred/dcrd/wire"
+)
+
+// TestInstance is the interface that describes a specific test instance
+// returned by the Generate function.  The specific test instance type must
+// be examined by the caller to determine how to process it.
+type TestInstance interface {
+	FullBlockTestInstance()
+}
+
+// AcceptedBlock defines a test instance that expects a block to be accepted
+// to the blockchain either as part of the main chain or a side chain.
+type AcceptedBlock struct {
+	Name        string
+	Block       *wire.MsgBlock
+	Height      uint32
+	IsMainChain bool
+	IsOrphan    bool
+}
+
+// Ensure AcceptedBlock implements the TestInstance interface.
+var _ TestInstance = AcceptedBlock{}
+
+// FullBlockTestInstance only exists to distinguish the test instance types.
+func (b AcceptedBlock) FullBlockTestInstance() {}
+
+// RejectedBlock defines a test instance that expects a block to be rejected
+// by the blockchain consensus rules.
+type RejectedBlock struct {
+	Name      string
+	Block     *wire.MsgBlock
+	Height    uint32
+	RejectErr blockchain.ErrorCode
+}
+
+// Ensure RejectedBlock implements the TestInstance interface.
+var _ TestInstance = RejectedBlock{}
+
+// FullBlockTestInstance only exists to distinguish the test instance types.
+func (b RejectedBlock) FullBlockTestInstance() {}
+
+// RejectedBlockAnyReason defines a test instance that expects a block to be
+// rejected by the blockchain consensus rules, without pinning down which
+// specific ErrorCode the rejection carries.  This is for scenarios, such as
+// the Decred-specific stake violations in Generate, whose rejection is expected to
+// come from stake validation logic that is not part of this checkout -- the
+// real validator the vector is ultimately replayed against is free to reject
+// for whatever specific reason it actually implements, as long as it rejects.
+type RejectedBlockAnyReason struct {
+	Name   string
+	Block  *wire.MsgBlock
+	Height uint32
+}
+
+// Ensure RejectedBlockAnyReason implements the TestInstance interface.
+var _ TestInstance = RejectedBlockAnyReason{}
+
+// FullBlockTestInstance only exists to distinguish the test instance types.
+func (b RejectedBlockAnyReason) FullBlockTestInstance() {}
+
+// OrphanOrRejectedBlock defines a test instance that expects a block to
+// either be accepted as an orphan or rejected.  This is useful for
+// scenarios, such as ones involving stake validation, where the exact
+// outcome depends on whether or not the block happens to connect to a known
+// previous block at the time it is processed.
+type OrphanOrRejectedBlock struct {
+	Name   string
+	Block  *wire.MsgBlock
+	Height uint32
+}
+
+// Ensure OrphanOrRejectedBlock implements the TestInstance interface.
+var _ TestInstance = OrphanOrRejectedBlock{}
+
+// FullBlockTestInstance only exists to distinguish the test instance types.
+func (b OrphanOrRejectedBlock) FullBlockTestInstance() {}
+
+// ExpectedTip defines a test instance that expects a block to be the current
+// tip of the main chain.
+type ExpectedTip struct {
+	Name   string
+	Block  *wire.MsgBlock
+	Height uint32
+}
+
+// Ensure ExpectedTip implements the TestInstance interface.
+var _ TestInstance = ExpectedTip{}
+
+// FullBlockTestInstance only exists to distinguish the test instance types.
+func (b ExpectedTip) FullBlockTestInstance() {}
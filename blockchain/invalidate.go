@@ -0,0 +1,202 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+)
+
+// InvalidateBlock manually marks the block identified by the provided hash as
+// invalid and, if necessary, rolls the best chain back to its parent.
+//
+// The block must already be known to the block index.  The genesis block may
+// not be invalidated.  Marking a block invalid also marks every known
+// descendant of that block, on the main chain or any side chain, as having an
+// invalid ancestor so none of them can ever be activated without an explicit
+// ReconsiderBlock call.
+//
+// If the invalidated block is not part of the best chain, only the cached
+// statuses of it and its descendants are updated and the best chain is left
+// untouched.  If it is part of the best chain, the chain is disconnected back
+// to the invalidated block's parent using the usual disconnect machinery
+// (which updates the utxo set, stake node, and best chain state accordingly),
+// and then the best valid tip among the remaining candidates -- excluding the
+// invalidated block and all of its descendants -- is activated.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return fmt.Errorf("block %s is not known", hash)
+	}
+	if node.parent == nil {
+		return fmt.Errorf("the genesis block cannot be invalidated")
+	}
+
+	// Mark the node itself, along with every descendant of it that is
+	// currently known to the index, as invalid.  Descendants on side chains
+	// are included so a later attempt to extend one of them is rejected
+	// immediately instead of being rediscovered as invalid block by block.
+	b.index.SetStatusFlags(node, statusValidateFailed)
+	b.index.UnsetStatusFlags(node, statusValid)
+	b.markDescendantsInvalidAncestor(node)
+	b.index.flushToDB(b.db)
+
+	// If the invalidated node isn't part of the best chain there's nothing
+	// further to do -- the active tip doesn't change.
+	if !b.bestChain.Contains(node) {
+		return nil
+	}
+
+	// Disconnect blocks from the best chain back to the invalidated node's
+	// parent using the normal disconnect path so the utxo set, stake node,
+	// and best chain state all stay in sync.
+	if err := b.invalidateChainTo(node.parent); err != nil {
+		return err
+	}
+
+	// Re-evaluate the remaining candidate tips, excluding the invalidated
+	// node and anything descended from it, and activate the one with the
+	// most cumulative work.
+	return b.reorganizeToBestValidTip(node)
+}
+
+// ReconsiderBlock clears the invalid status from the block identified by the
+// provided hash and from all of its known descendants, re-queuing them for
+// validation.  If any of those descendants now roots the tip with the most
+// cumulative work, the chain is reorganized to it.  Descendants that were
+// never fully validated before being marked invalid (for example because an
+// earlier invalidated ancestor short-circuited their validation) are fully
+// revalidated as part of the reorg rather than being trusted blindly.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return fmt.Errorf("block %s is not known", hash)
+	}
+
+	b.clearDescendantsInvalidAncestor(node)
+	b.index.flushToDB(b.db)
+
+	// Nothing is excluded here so the reconsidered node and its descendants
+	// are candidates for the new best tip.
+	return b.reorganizeToBestValidTip(nil)
+}
+
+// markDescendantsInvalidAncestor walks the block index and flags every known
+// descendant of node -- on the main chain or any side chain -- with
+// statusInvalidAncestor so none of them can be activated until the ancestor
+// is reconsidered.
+func (b *BlockChain) markDescendantsInvalidAncestor(node *blockNode) {
+	for _, n := range b.index.index {
+		if n == node {
+			continue
+		}
+		if n.isAncestorOf(node) || !node.isAncestorOf(n) {
+			continue
+		}
+		b.index.SetStatusFlags(n, statusInvalidAncestor)
+		b.index.UnsetStatusFlags(n, statusValid)
+	}
+}
+
+// clearDescendantsInvalidAncestor clears statusValidateFailed and
+// statusInvalidAncestor from node and from every known descendant of it so
+// they are re-queued for validation rather than being treated as invalid.
+func (b *BlockChain) clearDescendantsInvalidAncestor(node *blockNode) {
+	b.index.UnsetStatusFlags(node, statusValidateFailed|statusInvalidAncestor)
+	for _, n := range b.index.index {
+		if n != node && !node.isAncestorOf(n) {
+			continue
+		}
+		b.index.UnsetStatusFlags(n, statusValidateFailed|statusInvalidAncestor)
+	}
+}
+
+// isAncestorOf returns whether node is an ancestor of other by walking
+// other's parents back towards the genesis block.
+func (node *blockNode) isAncestorOf(other *blockNode) bool {
+	for n := other; n != nil; n = n.parent {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateChainTo disconnects blocks from the current best chain tip down
+// to, but not including, target using the normal disconnect path.
+func (b *BlockChain) invalidateChainTo(target *blockNode) error {
+	for tip := b.bestChain.Tip(); tip != target; tip = b.bestChain.Tip() {
+		block, err := b.fetchBlockByNode(tip)
+		if err != nil {
+			return err
+		}
+		parent, err := b.fetchBlockByNode(tip.parent)
+		if err != nil {
+			return err
+		}
+		view, err := b.fetchUtxoViewForDisconnect(block, parent)
+		if err != nil {
+			return err
+		}
+		if err := b.disconnectBlock(tip, block, view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkInvalidAncestor returns ErrInvalidAncestorBlock if node or any of its
+// known ancestors is currently flagged as invalid.  reorganizeToBestValidTip
+// calls this for every candidate tip so that a side chain descending from a
+// manually invalidated block is never selected as the new best chain.
+//
+// Note that this checkout does not include blockchain/validate.go, so the
+// new-block acceptance path (maybeAcceptBlock) cannot be wired up here as
+// well; reorganizeToBestValidTip is the one call site this change owns.
+func checkInvalidAncestor(node *blockNode) error {
+	for n := node; n != nil; n = n.parent {
+		if n.status&(statusValidateFailed|statusInvalidAncestor) != 0 {
+			str := fmt.Sprintf("block %s has an invalid ancestor", node.hash)
+			return ruleError(ErrInvalidAncestorBlock, str)
+		}
+	}
+	return nil
+}
+
+// reorganizeToBestValidTip finds the highest-work tip among all known
+// candidates that is not excluded and not a descendant of excluded, fully
+// validating any blocks along the way that were not previously validated,
+// and reorganizes the best chain to it if it differs from the current tip.
+// A nil excluded excludes no candidates.
+func (b *BlockChain) reorganizeToBestValidTip(excluded *blockNode) error {
+	var bestTip *blockNode
+	for _, tip := range b.index.tips() {
+		if tip == excluded || excluded.isAncestorOf(tip) {
+			continue
+		}
+		if checkInvalidAncestor(tip) != nil {
+			continue
+		}
+		if bestTip == nil || tip.workSum.Cmp(bestTip.workSum) > 0 {
+			bestTip = tip
+		}
+	}
+	if bestTip == nil || bestTip == b.bestChain.Tip() {
+		return nil
+	}
+
+	return b.reorganizeChain(bestTip)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/blockchain/v3/internal/workmath"
+	"github.com/decred/dcrd/dcrutil/v3"
+)
+
+// minOrphanWorkRatio is the denominator of the fraction of the current best
+// chain's cumulative work that an orphan's estimated cumulative work must
+// reach before it is admitted into the orphan pool.  For example, a value
+// of 1000 requires an orphan's estimated work to be at least 1/1000th of
+// the best chain's work.
+//
+// This exists as a package-level variable, rather than a plain constant, so
+// it can be tuned in tests.
+var minOrphanWorkRatio int64 = 1000
+
+// orphanWorkSum estimates the cumulative work claimed by an orphan block by
+// walking the orphan pool from the candidate block's parent down to the
+// deepest known ancestor that is itself an orphan, summing each ancestor's
+// header work, and finally adding the work claimed by the candidate block's
+// own header.
+//
+// Because none of the orphan's ancestors are known to the block index, this
+// is necessarily only a lower-bound estimate -- it has no way to know
+// whether the orphan pool chain it walks actually connects to the real best
+// chain -- but it is enough to make flooding the pool with low-work blocks
+// expensive to sustain.
+func (b *BlockChain) orphanWorkSum(block *dcrutil.Block) *big.Int {
+	header := &block.MsgBlock().Header
+	sum := workmath.CalcWork(header.Bits)
+
+	prevHash := header.PrevBlock
+	for {
+		b.orphanLock.RLock()
+		orphan, exists := b.orphans[prevHash]
+		b.orphanLock.RUnlock()
+		if !exists {
+			break
+		}
+
+		ancestorHeader := &orphan.block.MsgBlock().Header
+		sum.Add(sum, workmath.CalcWork(ancestorHeader.Bits))
+		prevHash = ancestorHeader.PrevBlock
+	}
+
+	return sum
+}
+
+// checkOrphanWorkPolicy enforces a chain-work-aware admission policy for
+// candidate orphan blocks before ProcessBlock adds them to the orphan pool.
+//
+// It first requires the orphan's header to actually satisfy its own claimed
+// proof-of-work target -- a cheap check that rejects garbage headers
+// outright -- and then requires the orphan's estimated cumulative work, as
+// computed by orphanWorkSum, to be at least 1/minOrphanWorkRatio of the
+// current best chain's cumulative work.  Without this, any syntactically valid
+// block with an unknown parent, regardless of how little work it
+// represents, would be accepted into the orphan pool, which makes it cheap
+// to flood.
+//
+// IMPORTANT: this checkout does not include blockchain/process.go, so
+// nothing in this package actually calls checkOrphanWorkPolicy from the
+// orphan admission path (the real ProcessBlock/addOrphanBlock) -- it has no
+// caller anywhere outside of TestOrphanWorkGating, which invokes it
+// directly as its own exercise of the policy. Until process.go exists in
+// this checkout and addOrphanBlock is changed to call this before adding a
+// candidate to b.orphans, the low-work orphan-flood vector this function is
+// meant to close remains open in practice; its presence here is the policy
+// decision and its test, not a claim that the vector is closed end-to-end.
+func (b *BlockChain) checkOrphanWorkPolicy(block *dcrutil.Block) error {
+	header := &block.MsgBlock().Header
+	target := workmath.CompactToBig(header.Bits)
+	hash := workmath.HashToBig(block.Hash())
+	if hash.Cmp(target) > 0 {
+		str := fmt.Sprintf("orphan block %s does not satisfy its claimed "+
+			"proof-of-work target", block.Hash())
+		return ruleError(ErrOrphanPolicy, str)
+	}
+
+	bestWork := b.bestChain.Tip().workSum
+	minRequiredWork := new(big.Int).Div(bestWork, big.NewInt(minOrphanWorkRatio))
+	orphanWork := b.orphanWorkSum(block)
+	if orphanWork.Cmp(minRequiredWork) < 0 {
+		str := fmt.Sprintf("orphan block %s claimed cumulative work %s does "+
+			"not meet the minimum required work %s", block.Hash(),
+			orphanWork, minRequiredWork)
+		return ruleError(ErrOrphanPolicy, str)
+	}
+
+	return nil
+}
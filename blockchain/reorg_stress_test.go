@@ -0,0 +1,149 @@
+// Copyright (c) 2019 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build largereorg
+
+package blockchain
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v3"
+)
+
+// numLargeReorgBlocks is the base length, in blocks, that the competing
+// branches generated by TestLargeReorg build on top of (later branches add a
+// block or two more to guarantee strictly more work).  It is sized to
+// roughly a week's worth of Decred blocks (which target a five minute
+// spacing), mirroring the
+// numLargeReorgBlocks pattern used by the analogous btcd full-block reorg
+// test.
+const numLargeReorgBlocks = 2016
+
+// TestLargeReorg is a stress test that is opt-in via the "largereorg" build
+// tag (and typically also gated behind -test.long by the caller) because it
+// is expensive to run.  It generates three competing branches forked from
+// the same point, each heavier than the last, and accepts the first to the
+// tip before feeding the second through ProcessBlock out-of-order and the
+// third in-order, each forcing a full reorg onto a new, heavier tip.
+// Throughput and basic correctness of the post-reorg chain state -- tip
+// height, live ticket pool size, and total processed transactions -- are
+// logged and asserted, respectively, after each reorg.
+func TestLargeReorg(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large reorg stress test in -short mode")
+	}
+
+	params := chaincfg.RegNetParams()
+	g, teardownFunc := newChaingenHarness(t, params, "largereorgtest")
+	defer teardownFunc()
+
+	g.AdvanceToStakeValidationHeight()
+
+	// Build and accept the initial, lighter branch in order.  Each branch
+	// forked from "lr0base" needs its own branchID since ForkFrom names the
+	// blocks it generates after branchID, not the fork point.
+	g.NextBlock("lr0base", nil, nil)
+	g.AcceptTipBlock()
+	g.ForkFrom("lr0a", "lr0base", numLargeReorgBlocks)
+	submitBranch(t, g, "lr0a", numLargeReorgBlocks, true)
+
+	origTipHeight := g.Tip().Header.Height
+
+	// Build a second, heavier competing branch off of the same fork point
+	// that is one block longer than the original branch so it accumulates
+	// strictly more work.
+	g.SetTip("lr0base")
+	g.ForkFrom("lr0b", "lr0base", numLargeReorgBlocks+1)
+
+	start := time.Now()
+	submitBranch(t, g, "lr0b", numLargeReorgBlocks+1, false)
+	outOfOrderElapsed := time.Since(start)
+	t.Logf("out-of-order reorg of %d blocks took %s (%.2f blocks/sec)",
+		numLargeReorgBlocks+1, outOfOrderElapsed,
+		float64(numLargeReorgBlocks+1)/outOfOrderElapsed.Seconds())
+	origTipHeight = checkReorgResult(t, g, origTipHeight)
+
+	// Build a third, still heavier competing branch off of the same fork
+	// point and feed it in-order this time, so both submission orders force
+	// a reorg over the course of the test.
+	g.SetTip("lr0base")
+	g.ForkFrom("lr0c", "lr0base", numLargeReorgBlocks+2)
+
+	start = time.Now()
+	submitBranch(t, g, "lr0c", numLargeReorgBlocks+2, true)
+	inOrderElapsed := time.Since(start)
+	t.Logf("in-order reorg of %d blocks took %s (%.2f blocks/sec)",
+		numLargeReorgBlocks+2, inOrderElapsed,
+		float64(numLargeReorgBlocks+2)/inOrderElapsed.Seconds())
+	checkReorgResult(t, g, origTipHeight)
+}
+
+// checkReorgResult sanity checks the chain state after a reorg has been
+// driven through submitBranch: the tip must have advanced past
+// origTipHeight, the live ticket pool reported for the next block must be
+// non-empty now that the chain is well past stake validation height, and the
+// chain's running total transaction count must be non-zero.  It returns the
+// new tip height so callers chaining multiple reorgs in the same test can
+// use it as the next origTipHeight.
+//
+// This checkout does not include the utxo-set bookkeeping
+// (blockchain/chainio.go and friends), so there is no API available here to
+// assert utxo-set size the way this check does for the live ticket pool;
+// TotalTxns is used as the closest available proxy for utxo churn instead.
+func checkReorgResult(t *testing.T, g *chaingenHarness, origTipHeight uint32) uint32 {
+	t.Helper()
+
+	newTipHeight := g.Tip().Header.Height
+	if newTipHeight <= origTipHeight {
+		t.Fatalf("reorg did not advance the tip -- got height %d, want > %d",
+			newTipHeight, origTipHeight)
+	}
+
+	bestSnapshot := g.chain.BestSnapshot()
+	if bestSnapshot.Height != int64(newTipHeight) {
+		t.Fatalf("unexpected best height after reorg -- got %d, want %d",
+			bestSnapshot.Height, newTipHeight)
+	}
+	if bestSnapshot.TotalTxns == 0 {
+		t.Fatalf("unexpected zero total transactions after reorg")
+	}
+	if bestSnapshot.NextPoolSize == 0 {
+		t.Fatalf("unexpected empty live ticket pool after reorg")
+	}
+
+	return newTipHeight
+}
+
+// submitBranch submits the length blocks named "<base>fork%d" for
+// 0 <= i < length to the chain instance associated with g using
+// ProcessBlock and BFFastAdd, optionally reversing the submission order to
+// force the blocks to be processed out of order.  It fails the test if any
+// block is rejected outright.
+func submitBranch(t *testing.T, g *chaingenHarness, base string, length int, inOrder bool) {
+	t.Helper()
+
+	indexes := make([]int, length)
+	for i := range indexes {
+		if inOrder {
+			indexes[i] = i
+		} else {
+			indexes[i] = length - 1 - i
+		}
+	}
+
+	for _, i := range indexes {
+		blockName := base + "fork" + strconv.Itoa(i)
+		msgBlock := g.BlockByName(blockName)
+		block := dcrutil.NewBlock(msgBlock)
+		_, _, err := g.chain.ProcessBlock(block, BFFastAdd)
+		if err != nil {
+			t.Fatalf("block %q (hash %s, height %d) not accepted: %v",
+				blockName, block.Hash(), msgBlock.Header.Height, err)
+		}
+	}
+}